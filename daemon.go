@@ -0,0 +1,344 @@
+//**********************************************************************
+//      daemon.go
+//**********************************************************************
+//  Autor    : Gerhard Quell - gquell@skequell.de
+//  Copyright: 2025 Gerhard Quell - SKEQuell
+//  Erstellt : 20250705
+//**********************************************************************
+// Beschreibung: -serve - sigo als residenter HTTP-Gateway fuer ein Team,
+//               mit HS256-JWT-Auth und pro Modell dauerhaften Circuit
+//               Breakern. Kein externes JWT-Paket, nur crypto/hmac +
+//               crypto/sha256 aus der Standardbibliothek.
+//**********************************************************************
+
+package main
+
+import (
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/base64"
+  "encoding/json"
+  "flag"
+  "fmt"
+  "net/http"
+  "os"
+  "strings"
+  "sync"
+  "time"
+)
+
+//**********************************************************************
+// jwtSign produces a compact HS256 JWT: base64url(header).base64url(claims).base64url(signature).
+func jwtSign(secret []byte, claims map[string]interface{}) (string, error) {
+  header := map[string]string{"alg": "HS256", "typ": "JWT"}
+
+  headerJSON, err := json.Marshal(header)
+  if err != nil {
+    return "", err
+  }
+  claimsJSON, err := json.Marshal(claims)
+  if err != nil {
+    return "", err
+  }
+
+  signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+    base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+  mac := hmac.New(sha256.New, secret)
+  mac.Write([]byte(signingInput))
+  sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+  return signingInput + "." + sig, nil
+}
+
+//**********************************************************************
+// jwtVerify checks the signature plus the exp/iss claims and returns the
+// decoded claim set.
+func jwtVerify(secret []byte, token string) (map[string]interface{}, error) {
+  parts := strings.Split(token, ".")
+  if len(parts) != 3 {
+    return nil, fmt.Errorf("malformed token")
+  }
+
+  mac := hmac.New(sha256.New, secret)
+  mac.Write([]byte(parts[0] + "." + parts[1]))
+  expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+  if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+    return nil, fmt.Errorf("bad signature")
+  }
+
+  claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+  if err != nil {
+    return nil, fmt.Errorf("bad claims encoding")
+  }
+
+  var claims map[string]interface{}
+  if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+    return nil, fmt.Errorf("bad claims json")
+  }
+
+  if iss, _ := claims["iss"].(string); iss != "sigo" {
+    return nil, fmt.Errorf("bad issuer")
+  }
+
+  exp, ok := claims["exp"].(float64)
+  if !ok || time.Now().Unix() > int64(exp) {
+    return nil, fmt.Errorf("token expired")
+  }
+
+  return claims, nil
+}
+
+//**********************************************************************
+// runTokenCommand implements `sigo token --user alice --ttl 24h`.
+func runTokenCommand(args []string) {
+  fs := flag.NewFlagSet("token", flag.ExitOnError)
+  user := fs.String("user", "", "Subject claim for the token")
+  ttl := fs.Duration("ttl", time.Hour, "Token time-to-live")
+  fs.Parse(args)
+
+  if *user == "" {
+    logError("token: -user is required")
+    os.Exit(1)
+  }
+
+  secret := []byte(os.Getenv("SIGO_JWT_SECRET"))
+  if len(secret) == 0 {
+    logError("token: SIGO_JWT_SECRET not set")
+    os.Exit(1)
+  }
+
+  tok, err := jwtSign(secret, map[string]interface{}{
+    "sub": *user,
+    "iss": "sigo",
+    "exp": time.Now().Add(*ttl).Unix(),
+  })
+  if err != nil {
+    logError("token: %v", err)
+    os.Exit(1)
+  }
+
+  fmt.Println(tok)
+}
+
+//**********************************************************************
+// requireAuth wraps a handler, demanding a valid HS256 bearer token.
+func requireAuth(secret []byte, next http.HandlerFunc) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    authz := r.Header.Get("Authorization")
+    tok := strings.TrimPrefix(authz, "Bearer ")
+    if tok == "" || tok == authz {
+      http.Error(w, "missing bearer token", http.StatusUnauthorized)
+      return
+    }
+    if _, err := jwtVerify(secret, tok); err != nil {
+      http.Error(w, fmt.Sprintf("unauthorized: %v", err), http.StatusUnauthorized)
+      return
+    }
+    next(w, r)
+  }
+}
+
+//**********************************************************************
+// daemonServer holds the state that needs to survive across requests -
+// most importantly the per-model circuit breakers, which today's one-shot
+// CLI recreates (and so never actually trips) on every invocation.
+type daemonServer struct {
+  secret    []byte
+  breakers  sync.Map // model name -> *CircuitBreaker
+  providers sync.Map // model name -> Provider
+}
+
+//**********************************************************************
+func (d *daemonServer) breakerFor(model string) *CircuitBreaker {
+  v, _ := d.breakers.LoadOrStore(model, NewCircuitBreaker())
+  return v.(*CircuitBreaker)
+}
+
+//**********************************************************************
+// providerFor dials a model's provider once and reuses it across requests
+// - rebuilding it per request would redial a fresh gRPC connection every
+// time and defeat both the connection reuse and the persistent breaker
+// state the daemon exists for. cfg is only consulted on a cache miss, so
+// the caller is expected to have already loaded (and validated) it.
+func (d *daemonServer) providerFor(model string, cfg *ProviderConfig) (Provider, error) {
+  if v, ok := d.providers.Load(model); ok {
+    return v.(Provider), nil
+  }
+
+  provider, err := newProvider(cfg, defaultConnectTimeout)
+  if err != nil {
+    return nil, err
+  }
+
+  actual, loaded := d.providers.LoadOrStore(model, provider)
+  if loaded {
+    if closer, ok := provider.(interface{ Close() error }); ok {
+      closer.Close()
+    }
+    return actual.(Provider), nil
+  }
+  return provider, nil
+}
+
+//**********************************************************************
+type completeRequest struct {
+  Model     string `json:"model"`
+  Prompt    string `json:"prompt"`
+  Session   string `json:"session,omitempty"`
+  MaxTokens int    `json:"max_tokens"`
+  Stream    bool   `json:"stream,omitempty"`
+}
+
+//**********************************************************************
+// handleComplete implements POST /v1/complete.
+func (d *daemonServer) handleComplete(w http.ResponseWriter, r *http.Request) {
+  if r.Method != http.MethodPost {
+    http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    return
+  }
+
+  var req completeRequest
+  if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+    http.Error(w, fmt.Sprintf("bad request: %v", err), http.StatusBadRequest)
+    return
+  }
+  if req.MaxTokens == 0 {
+    req.MaxTokens = 1024
+  }
+  if req.Session != "" && !isSafeName(req.Session) {
+    http.Error(w, "invalid session id", http.StatusBadRequest)
+    return
+  }
+
+  cfg, err := loadConfig(req.Model)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+    return
+  }
+  provider, err := d.providerFor(req.Model, cfg)
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusBadGateway)
+    return
+  }
+
+  session := loadSession(req.Session, req.Model)
+  contextPrompt := session.buildPrompt(req.Prompt)
+  breaker := d.breakerFor(req.Model)
+
+  if req.Stream {
+    d.handleCompleteStream(w, r, provider, breaker, session, req, contextPrompt)
+    return
+  }
+
+  var resp Response
+  resp.Model = req.Model
+  resp.PID = os.Getpid()
+  resp.Timestamp = time.Now().Unix()
+  resp.Prompt = req.Prompt
+
+  start := time.Now()
+  err = breaker.Do(func() error {
+    result, err := provider.Call(r.Context(), contextPrompt, req.MaxTokens)
+    if err != nil {
+      return err
+    }
+    resp.Response = result
+    return nil
+  })
+  resp.Duration = time.Since(start) / time.Millisecond
+
+  if err != nil {
+    resp.Error = err.Error()
+  } else if req.Session != "" {
+    session.addMessage("user", req.Prompt)
+    session.addMessage("assistant", resp.Response)
+    session.save(req.Session, req.Model)
+  }
+
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(resp)
+}
+
+//**********************************************************************
+// handleCompleteStream is the Stream:true branch of /v1/complete: it
+// flushes chunks to the client as they arrive instead of buffering the
+// whole completion.
+func (d *daemonServer) handleCompleteStream(w http.ResponseWriter, r *http.Request, provider Provider, breaker *CircuitBreaker, session *Session, req completeRequest, contextPrompt string) {
+  sp, ok := provider.(StreamingProvider)
+  if !ok {
+    http.Error(w, fmt.Sprintf("model %q does not support streaming", req.Model), http.StatusBadRequest)
+    return
+  }
+  flusher, ok := w.(http.Flusher)
+  if !ok {
+    http.Error(w, "streaming unsupported by this transport", http.StatusInternalServerError)
+    return
+  }
+
+  // Declare the trailer up front (required by net/http) so we can still
+  // tell the client a breaker-open or mid-stream failure happened after
+  // the 200 and chunks have already gone out - the JSON body's "error"
+  // field can't do that once headers are flushed.
+  w.Header().Set("Trailer", "X-Sigo-Error")
+  w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+  w.WriteHeader(http.StatusOK)
+
+  var full string
+  err := breaker.Do(func() error {
+    result, err := sp.CallStream(r.Context(), contextPrompt, req.MaxTokens, func(chunk string) {
+      fmt.Fprint(w, chunk)
+      flusher.Flush()
+    })
+    full = result
+    return err
+  })
+
+  if err != nil {
+    w.Header().Set("X-Sigo-Error", err.Error())
+    return
+  }
+
+  if req.Session != "" {
+    session.addMessage("user", req.Prompt)
+    session.addMessage("assistant", full)
+    session.save(req.Session, req.Model)
+  }
+}
+
+//**********************************************************************
+// handleGetSession implements GET /v1/sessions/{id}?model=<model>.
+func (d *daemonServer) handleGetSession(w http.ResponseWriter, r *http.Request) {
+  if r.Method != http.MethodGet {
+    http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    return
+  }
+
+  id := strings.TrimPrefix(r.URL.Path, "/v1/sessions/")
+  model := r.URL.Query().Get("model")
+  if id == "" || model == "" {
+    http.Error(w, "session id and model query param are required", http.StatusBadRequest)
+    return
+  }
+  if !isSafeName(id) || !isSafeName(model) {
+    http.Error(w, "invalid session id or model", http.StatusBadRequest)
+    return
+  }
+
+  session := loadSession(id, model)
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(session)
+}
+
+//**********************************************************************
+// runServe starts the daemon and blocks until it exits.
+func runServe(addr string, secret []byte) error {
+  d := &daemonServer{secret: secret}
+
+  mux := http.NewServeMux()
+  mux.HandleFunc("/v1/complete", requireAuth(secret, d.handleComplete))
+  mux.HandleFunc("/v1/sessions/", requireAuth(secret, d.handleGetSession))
+
+  fmt.Fprintf(os.Stderr, "sigo serving on %s\n", addr)
+  return http.ListenAndServe(addr, mux)
+}
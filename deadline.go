@@ -0,0 +1,53 @@
+//**********************************************************************
+//      deadline.go
+//**********************************************************************
+//  Autor    : Gerhard Quell - gquell@skequell.de
+//  Copyright: 2025 Gerhard Quell - SKEQuell
+//  Erstellt : 20250707
+//**********************************************************************
+// Beschreibung: Zwei getrennte Deadlines statt einer - analog zum
+//               deadlineTimer-Muster aus net: eine kurze Connect/
+//               First-Byte-Deadline, die bei jedem Retry neu gesetzt
+//               wird, und eine Wallclock-Deadline, die ueber alle
+//               Retries hinweg durchgereicht wird. Ohne diese Trennung
+//               killt ein einzelner context.WithTimeout auch langsame
+//               Streaming-Antworten mit, und Retries teilen sich dasselbe
+//               Budget wie vorherige Versuche.
+//**********************************************************************
+
+package main
+
+import (
+  "context"
+  "time"
+)
+
+//**********************************************************************
+// defaultConnectTimeout bounds how long one attempt may take to receive
+// its first response byte, for call sites that don't expose their own
+// -connect-timeout flag (fanout, daemon).
+const defaultConnectTimeout = 10 * time.Second
+
+//**********************************************************************
+// newAttemptContext derives a per-attempt context from the overall
+// (all-retries) deadline in overall. It also fails early if connectTimeout
+// elapses before the caller calls arrived() - mirroring a short-lived
+// cancel channel that closes on timeout, reset fresh on every retry.
+// Once the first response byte has arrived, call arrived() to drop the
+// connect deadline and let the overall deadline govern the rest of the
+// attempt (e.g. reading a streamed body). Always call cancel when the
+// attempt is done, success or not.
+func newAttemptContext(overall context.Context, connectTimeout time.Duration) (ctx context.Context, arrived func(), cancel context.CancelFunc) {
+  attemptCtx, cancelAttempt := context.WithCancel(overall)
+  timer := time.AfterFunc(connectTimeout, cancelAttempt)
+
+  arrived = func() {
+    timer.Stop()
+  }
+  cancel = func() {
+    timer.Stop()
+    cancelAttempt()
+  }
+
+  return attemptCtx, arrived, cancel
+}
@@ -0,0 +1,341 @@
+//**********************************************************************
+//      fanout.go
+//**********************************************************************
+//  Autor    : Gerhard Quell - gquell@skequell.de
+//  Copyright: 2025 Gerhard Quell - SKEQuell
+//  Erstellt : 20250704
+//**********************************************************************
+// Beschreibung: -fanout/-strategy - dieselbe Anfrage an mehrere Modelle
+//               gleichzeitig schicken und per first/all/vote/judge:X
+//               eine Antwort auswaehlen.
+//**********************************************************************
+
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "os"
+  "strings"
+  "sync"
+  "time"
+)
+
+//**********************************************************************
+// fanoutResult is one model's answer to a fanned-out prompt.
+type fanoutResult struct {
+  Model    string
+  Response string
+  Err      error
+  Duration time.Duration
+}
+
+//**********************************************************************
+// runFanout dispatches prompt to every model concurrently, each with its
+// own provider, circuit breaker and timeout, and collects every result
+// (successful or not) in the same order as models.
+func runFanout(ctx context.Context, models []string, timeout, maxTokens int, prompt string) []fanoutResult {
+  results := make([]fanoutResult, len(models))
+  var wg sync.WaitGroup
+
+  for i, m := range models {
+    wg.Add(1)
+    go func(i int, model string) {
+      defer wg.Done()
+      results[i] = callOneForFanout(ctx, model, timeout, maxTokens, prompt)
+    }(i, strings.TrimSpace(m))
+  }
+
+  wg.Wait()
+  return results
+}
+
+//**********************************************************************
+func callOneForFanout(ctx context.Context, model string, timeout, maxTokens int, prompt string) fanoutResult {
+  start := time.Now()
+
+  cfg, err := loadConfig(model)
+  if err != nil {
+    return fanoutResult{Model: model, Err: err}
+  }
+
+  provider, err := newProvider(cfg, defaultConnectTimeout)
+  if err != nil {
+    return fanoutResult{Model: model, Err: err}
+  }
+  if closer, ok := provider.(interface{ Close() error }); ok {
+    defer closer.Close()
+  }
+
+  callCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+  defer cancel()
+
+  breaker := NewCircuitBreaker()
+  var response string
+  err = breaker.Do(func() error {
+    result, err := provider.Call(callCtx, prompt, maxTokens)
+    if err != nil {
+      return err
+    }
+    response = result
+    return nil
+  })
+
+  return fanoutResult{Model: model, Response: response, Err: err, Duration: time.Since(start)}
+}
+
+//**********************************************************************
+func successfulResults(results []fanoutResult) []fanoutResult {
+  ok := make([]fanoutResult, 0, len(results))
+  for _, r := range results {
+    if r.Err == nil {
+      ok = append(ok, r)
+    }
+  }
+  return ok
+}
+
+//**********************************************************************
+// runFanoutFirst races every model concurrently and returns as soon as
+// the first one succeeds, cancelling the rest - unlike pickWinner's
+// other strategies, "first" must not wait on a slow or hung model just
+// to find out it lost.
+func runFanoutFirst(ctx context.Context, models []string, timeout, maxTokens int, prompt string) fanoutResult {
+  raceCtx, cancel := context.WithCancel(ctx)
+  defer cancel()
+
+  resultCh := make(chan fanoutResult, len(models))
+  var wg sync.WaitGroup
+
+  for _, m := range models {
+    wg.Add(1)
+    go func(model string) {
+      defer wg.Done()
+      resultCh <- callOneForFanout(raceCtx, model, timeout, maxTokens, prompt)
+    }(strings.TrimSpace(m))
+  }
+
+  go func() {
+    wg.Wait()
+    close(resultCh)
+  }()
+
+  var lastErr error
+  for r := range resultCh {
+    if r.Err == nil {
+      cancel() // we have our winner, stop the rest
+      return r
+    }
+    lastErr = r.Err
+  }
+
+  if lastErr == nil {
+    lastErr = fmt.Errorf("all models failed")
+  }
+  return fanoutResult{Err: lastErr}
+}
+
+//**********************************************************************
+// pickWinner applies -strategy to a fanout result set and returns the
+// winning model name and its response. "first" and "all" are handled by
+// the caller directly: "first" races instead of comparing Duration after
+// the fact, and "all" has no single winner.
+func pickWinner(strategy string, results []fanoutResult, timeout int) (model, response string, err error) {
+  ok := successfulResults(results)
+  if len(ok) == 0 {
+    return "", "", fmt.Errorf("all models failed")
+  }
+
+  switch {
+  case strategy == "vote":
+    winner := voteWinner(ok)
+    return winner.Model, winner.Response, nil
+
+  case strings.HasPrefix(strategy, "judge:"):
+    return judgeWinner(strings.TrimPrefix(strategy, "judge:"), ok, timeout)
+
+  default:
+    return "", "", fmt.Errorf("unknown strategy: %s", strategy)
+  }
+}
+
+//**********************************************************************
+// voteWinner picks the candidate whose normalized text is, on average,
+// most similar to the others - a simple token-Jaccard consensus.
+func voteWinner(results []fanoutResult) fanoutResult {
+  best := results[0]
+  bestScore := -1.0
+
+  for _, r := range results {
+    var total float64
+    for _, other := range results {
+      if other.Model == r.Model {
+        continue
+      }
+      total += jaccard(r.Response, other.Response)
+    }
+    if total > bestScore {
+      bestScore = total
+      best = r
+    }
+  }
+
+  return best
+}
+
+//**********************************************************************
+// jaccard scores two texts by the overlap of their lowercased word sets.
+func jaccard(a, b string) float64 {
+  setA := tokenSet(a)
+  setB := tokenSet(b)
+  if len(setA) == 0 && len(setB) == 0 {
+    return 1
+  }
+
+  intersection := 0
+  for tok := range setA {
+    if setB[tok] {
+      intersection++
+    }
+  }
+
+  union := len(setA) + len(setB) - intersection
+  if union == 0 {
+    return 0
+  }
+  return float64(intersection) / float64(union)
+}
+
+//**********************************************************************
+func tokenSet(s string) map[string]bool {
+  set := make(map[string]bool)
+  for _, tok := range strings.Fields(strings.ToLower(s)) {
+    set[tok] = true
+  }
+  return set
+}
+
+//**********************************************************************
+// judgeWinner sends every candidate response to judgeModel with a fixed
+// rubric prompt and returns whichever candidate the arbiter names. timeout
+// bounds the arbiter call the same way it bounds every candidate call -
+// a hung judge must not hold the request open forever.
+func judgeWinner(judgeModel string, results []fanoutResult, timeout int) (string, string, error) {
+  cfg, err := loadConfig(judgeModel)
+  if err != nil {
+    return "", "", fmt.Errorf("judge config: %v", err)
+  }
+
+  provider, err := newProvider(cfg, defaultConnectTimeout)
+  if err != nil {
+    return "", "", fmt.Errorf("judge provider: %v", err)
+  }
+  if closer, ok := provider.(interface{ Close() error }); ok {
+    defer closer.Close()
+  }
+
+  var rubric strings.Builder
+  rubric.WriteString("You are judging candidate answers from different models to the same prompt. ")
+  rubric.WriteString("Reply with ONLY one line, exactly: WINNER: <name>, using the candidate name exactly as labeled below.\n\n")
+  for _, r := range results {
+    fmt.Fprintf(&rubric, "=== %s ===\n%s\n\n", r.Model, r.Response)
+  }
+
+  judgeCtx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+  defer cancel()
+
+  verdict, err := provider.Call(judgeCtx, rubric.String(), 50)
+  if err != nil {
+    return "", "", fmt.Errorf("judge call: %v", err)
+  }
+
+  name := judgeVerdictName(verdict)
+  for _, r := range results {
+    if r.Model == name {
+      return r.Model, r.Response, nil
+    }
+  }
+
+  // Arbiter didn't name a known candidate cleanly - fall back to the
+  // first one rather than failing the whole request.
+  return results[0].Model, results[0].Response, nil
+}
+
+//**********************************************************************
+// judgeVerdictName extracts the candidate name from a "WINNER: <name>"
+// line, tolerating surrounding whitespace/quoting from a model that
+// didn't follow the rubric to the letter. Matching is exact against the
+// extracted name, never substring containment - one model's name can be
+// a substring of another's (e.g. "gpt4" in "gpt4-turbo").
+func judgeVerdictName(verdict string) string {
+  for _, line := range strings.Split(verdict, "\n") {
+    line = strings.TrimSpace(line)
+    if rest, ok := strings.CutPrefix(line, "WINNER:"); ok {
+      return strings.Trim(strings.TrimSpace(rest), `"'`)
+    }
+  }
+  return strings.Trim(strings.TrimSpace(verdict), `"'`)
+}
+
+//**********************************************************************
+// runFanoutHandler runs -fanout end-to-end: dispatch, strategy, session
+// bookkeeping and output. Returns false if the process should exit
+// non-zero.
+func runFanoutHandler(models []string, strategy, sessionID, model string, maxTokens, timeout int, jsonOut bool, prompt, contextPrompt string, session *Session) bool {
+  if strategy == "" || strategy == "first" {
+    winner := runFanoutFirst(context.Background(), models, timeout, maxTokens, contextPrompt)
+    if winner.Err != nil {
+      logError("Fanout: %v", winner.Err)
+      return false
+    }
+    return finishFanout(winner.Model, winner.Response, []fanoutResult{winner}, sessionID, model, jsonOut, prompt, session)
+  }
+
+  results := runFanout(context.Background(), models, timeout, maxTokens, contextPrompt)
+
+  if strategy == "all" {
+    out := make(map[string]interface{}, len(results))
+    for _, r := range results {
+      if r.Err != nil {
+        out[r.Model] = map[string]string{"error": r.Err.Error()}
+      } else {
+        out[r.Model] = r.Response
+      }
+    }
+    json.NewEncoder(os.Stdout).Encode(out)
+    return true
+  }
+
+  winnerModel, response, err := pickWinner(strategy, results, timeout)
+  if err != nil {
+    logError("Fanout: %v", err)
+    return false
+  }
+
+  return finishFanout(winnerModel, response, results, sessionID, model, jsonOut, prompt, session)
+}
+
+//**********************************************************************
+// finishFanout records the session bookkeeping shared by every strategy
+// but "all" - candidates, the winning model - and writes the final
+// output.
+func finishFanout(winner, response string, results []fanoutResult, sessionID, model string, jsonOut bool, prompt string, session *Session) bool {
+  if sessionID != "" {
+    session.addMessage("user", prompt)
+    session.addMessage("assistant", response)
+    session.Winner = winner
+    session.Candidates = nil
+    for _, r := range successfulResults(results) {
+      session.Candidates = append(session.Candidates, Message{Role: r.Model, Content: r.Response})
+    }
+    session.save(sessionID, model)
+  }
+
+  if jsonOut {
+    json.NewEncoder(os.Stdout).Encode(map[string]string{"winner": winner, "response": response})
+  } else {
+    fmt.Println(response)
+  }
+  return true
+}
@@ -0,0 +1,154 @@
+//go:build linux || darwin
+
+//**********************************************************************
+//      plugins.go
+//**********************************************************************
+//  Autor    : Gerhard Quell - gquell@skequell.de
+//  Copyright: 2025 Gerhard Quell - SKEQuell
+//  Erstellt : 20250706
+//**********************************************************************
+// Beschreibung: Custom Provider per Go-Plugin aus .sigo/plugins/<typ>.so -
+//               fuer proprietaere oder exotische Backends (Bedrock,
+//               Vertex, on-prem Gateways), ohne den Core patchen zu
+//               muessen. Go-Plugins existieren nur unter linux/darwin,
+//               siehe plugins_unsupported.go fuer die Gegenseite.
+//**********************************************************************
+
+package main
+
+import (
+  "context"
+  "fmt"
+  "os"
+  "path/filepath"
+  "plugin"
+  "strings"
+  "sync"
+)
+
+//**********************************************************************
+// PluginProvider is what a .sigo/plugins/<type>.so must export as a
+// package-level symbol named "Provider". A plugin is built as its own
+// package main, which can never import the host's package main, so this
+// contract sticks to stdlib types instead of *ProviderConfig - that's
+// enough for the host to type-assert the loaded symbol against its own
+// PluginProvider interface.
+type PluginProvider interface {
+  Call(ctx context.Context, endpoint, model, apiKey string, headers map[string]string, prompt string, maxTokens int) (string, error)
+  Stream(ctx context.Context, endpoint, model, apiKey string, headers map[string]string, prompt string, maxTokens int, onChunk func(chunk string)) error
+}
+
+//**********************************************************************
+var pluginCache sync.Map // type name -> PluginProvider
+
+//**********************************************************************
+// loadPlugin opens and caches .sigo/plugins/<typeName>.so, keyed by type
+// name so repeated calls within a process reuse the same loaded plugin.
+func loadPlugin(typeName string) (PluginProvider, error) {
+  if cached, ok := pluginCache.Load(typeName); ok {
+    return cached.(PluginProvider), nil
+  }
+
+  path := filepath.Join(".sigo", "plugins", typeName+".so")
+  p, err := plugin.Open(path)
+  if err != nil {
+    return nil, fmt.Errorf("plugin %s: %v", typeName, err)
+  }
+
+  sym, err := p.Lookup("Provider")
+  if err != nil {
+    return nil, fmt.Errorf("plugin %s: missing Provider symbol: %v", typeName, err)
+  }
+
+  impl, ok := sym.(PluginProvider)
+  if !ok {
+    return nil, fmt.Errorf("plugin %s: Provider does not implement the expected interface", typeName)
+  }
+
+  pluginCache.Store(typeName, impl)
+  return impl, nil
+}
+
+//**********************************************************************
+// pluginProviderAdapter lets a loaded plugin satisfy StreamingProvider.
+type pluginProviderAdapter struct {
+  cfg  *ProviderConfig
+  impl PluginProvider
+}
+
+func (p *pluginProviderAdapter) Call(ctx context.Context, prompt string, maxTokens int) (string, error) {
+  return p.impl.Call(ctx, p.cfg.Endpoint, p.cfg.Model, p.cfg.APIKey, p.cfg.Headers, prompt, maxTokens)
+}
+
+func (p *pluginProviderAdapter) CallStream(ctx context.Context, prompt string, maxTokens int, onChunk StreamFunc) (string, error) {
+  var full strings.Builder
+  err := p.impl.Stream(ctx, p.cfg.Endpoint, p.cfg.Model, p.cfg.APIKey, p.cfg.Headers, prompt, maxTokens, func(chunk string) {
+    full.WriteString(chunk)
+    if onChunk != nil {
+      onChunk(chunk)
+    }
+  })
+  return full.String(), err
+}
+
+//**********************************************************************
+// runPluginCommand implements `sigo plugin new <name>`.
+func runPluginCommand(args []string) {
+  if len(args) < 2 || args[0] != "new" {
+    logError("usage: sigo plugin new <name>")
+    os.Exit(1)
+  }
+
+  if err := scaffoldPlugin(args[1]); err != nil {
+    logError("plugin: %v", err)
+    os.Exit(1)
+  }
+}
+
+//**********************************************************************
+// scaffoldPlugin writes .sigo/plugins/<name>/main.go with the build tag
+// and method stubs needed to satisfy PluginProvider.
+func scaffoldPlugin(name string) error {
+  dir := filepath.Join(".sigo", "plugins", name)
+  if err := os.MkdirAll(dir, 0755); err != nil {
+    return err
+  }
+
+  path := filepath.Join(dir, "main.go")
+  if _, err := os.Stat(path); err == nil {
+    return fmt.Errorf("%s already exists", path)
+  }
+
+  if err := os.WriteFile(path, []byte(fmt.Sprintf(pluginTemplate, name)), 0644); err != nil {
+    return err
+  }
+
+  fmt.Printf("scaffolded %s\n", path)
+  fmt.Printf("build with: go build -buildmode=plugin -o .sigo/plugins/%s.so %s\n", name, path)
+  return nil
+}
+
+//**********************************************************************
+const pluginTemplate = `//go:build linux || darwin
+
+// Package main is a sigo provider plugin for the %[1]s backend.
+// Build with: go build -buildmode=plugin -o .sigo/plugins/%[1]s.so .
+package main
+
+import "context"
+
+type %[1]sProvider struct{}
+
+func (%[1]sProvider) Call(ctx context.Context, endpoint, model, apiKey string, headers map[string]string, prompt string, maxTokens int) (string, error) {
+	// TODO: call the %[1]s backend and return its completion.
+	return "", nil
+}
+
+func (%[1]sProvider) Stream(ctx context.Context, endpoint, model, apiKey string, headers map[string]string, prompt string, maxTokens int, onChunk func(chunk string)) error {
+	// TODO: stream tokens from the %[1]s backend via onChunk.
+	return nil
+}
+
+// Provider is the symbol sigo looks up via plugin.Lookup("Provider").
+var Provider %[1]sProvider
+`
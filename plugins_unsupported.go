@@ -0,0 +1,53 @@
+//go:build !linux && !darwin
+
+//**********************************************************************
+//      plugins_unsupported.go
+//**********************************************************************
+//  Autor    : Gerhard Quell - gquell@skequell.de
+//  Copyright: 2025 Gerhard Quell - SKEQuell
+//  Erstellt : 20250706
+//**********************************************************************
+// Beschreibung: Gegenstueck zu plugins.go auf Plattformen ohne den
+//               plugin-Package (Go-Plugins gibt es nur fuer linux/darwin).
+//**********************************************************************
+
+package main
+
+import (
+  "context"
+  "fmt"
+  "os"
+)
+
+//**********************************************************************
+// PluginProvider mirrors the linux/darwin contract so other files can
+// reference it unconditionally; loadPlugin always fails here.
+type PluginProvider interface {
+  Call(ctx context.Context, endpoint, model, apiKey string, headers map[string]string, prompt string, maxTokens int) (string, error)
+  Stream(ctx context.Context, endpoint, model, apiKey string, headers map[string]string, prompt string, maxTokens int, onChunk func(chunk string)) error
+}
+
+//**********************************************************************
+func loadPlugin(typeName string) (PluginProvider, error) {
+  return nil, fmt.Errorf("plugin %s: Go plugins are not supported on this platform", typeName)
+}
+
+//**********************************************************************
+type pluginProviderAdapter struct {
+  cfg  *ProviderConfig
+  impl PluginProvider
+}
+
+func (p *pluginProviderAdapter) Call(ctx context.Context, prompt string, maxTokens int) (string, error) {
+  return "", fmt.Errorf("plugins are not supported on this platform")
+}
+
+func (p *pluginProviderAdapter) CallStream(ctx context.Context, prompt string, maxTokens int, onChunk StreamFunc) (string, error) {
+  return "", fmt.Errorf("plugins are not supported on this platform")
+}
+
+//**********************************************************************
+func runPluginCommand(args []string) {
+  logError("plugin: Go plugins are not supported on this platform")
+  os.Exit(1)
+}
@@ -5,7 +5,7 @@
 //  CoAutor  : claude opus 4
 //  Copyright: 2025 Gerhard Quell - SKEQuell
 //  Erstellt : 20250630
-//  Aenderung: 20250701
+//  Aenderung: 20250707
 //**********************************************************************
 // Beschreibung: Universelle SI/KI Engine - KISS Prinzip
 //               Eine Datei, keine Dependencies, pure Power
@@ -24,6 +24,7 @@ import (
   "net/http"
   "os"
   "path/filepath"
+  "regexp"
   "strings"
   "sync"
   "time"
@@ -50,7 +51,9 @@ type Response struct {
 //**********************************************************************
 // Session handling - minimal
 type Session struct {
-  History []Message `json:"history"`
+  History    []Message `json:"history"`
+  Candidates []Message `json:"candidates,omitempty"` // fanout: every candidate from the last -fanout call, Role set to the model name
+  Winner     string    `json:"winner,omitempty"`      // fanout: the model name pickWinner/runFanoutFirst chose
 }
 
 type Message struct {
@@ -61,11 +64,13 @@ type Message struct {
 //**********************************************************************
 // Provider Config
 type ProviderConfig struct {
-  Endpoint  string            `json:"endpoint"`
-  Model     string            `json:"model"`
-  APIKey    string            `json:"api_key"`
-  Headers   map[string]string `json:"headers,omitempty"`
-  Type      string            `json:"type"` // "anthropic", "openai", "custom"
+  Endpoint   string            `json:"endpoint"`
+  Model      string            `json:"model"`
+  APIKey     string            `json:"api_key"`
+  Headers    map[string]string `json:"headers,omitempty"`
+  Type       string            `json:"type"`                   // "anthropic", "openai", "ollama", "grpc", "custom"
+  Address    string            `json:"address,omitempty"`      // grpc: host:port of the local runner
+  SocketPath string            `json:"socket_path,omitempty"`  // grpc: unix socket of the local runner
 }
 
 //**********************************************************************
@@ -110,13 +115,26 @@ func (cb *CircuitBreaker) Do(fn func() error) error {
   return err
 }
 
+//**********************************************************************
+// safeNameRE bounds the characters allowed in a model name or session ID
+// once they're spliced into a filesystem path (.sessions/%s-%s.json,
+// .%s.config). Both values reach loadSession/save/loadConfig from the
+// CLI's own -m/-s flags and, since -serve, straight from an untrusted
+// request body/query string - without this check "../../etc/passwd" is
+// a valid "model" and reads whatever the daemon process can see.
+var safeNameRE = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func isSafeName(s string) bool {
+  return s != "" && !strings.Contains(s, "..") && safeNameRE.MatchString(s)
+}
+
 //**********************************************************************
 // Session functions
 func loadSession(sessionID, model string) *Session {
-  if sessionID == "" {
+  if sessionID == "" || !isSafeName(sessionID) || !isSafeName(model) {
     return &Session{}
   }
-  
+
   path := fmt.Sprintf(".sessions/%s-%s.json", model, sessionID)
   data, err := os.ReadFile(path)
   if err != nil {
@@ -130,7 +148,7 @@ func loadSession(sessionID, model string) *Session {
 
 //**********************************************************************
 func (s *Session) save(sessionID, model string) {
-  if sessionID == "" {
+  if sessionID == "" || !isSafeName(sessionID) || !isSafeName(model) {
     return
   }
   
@@ -176,6 +194,10 @@ func (s *Session) buildPrompt(newPrompt string) string {
 //**********************************************************************
 // Config loading
 func loadConfig(model string) (*ProviderConfig, error) {
+  if !isSafeName(model) {
+    return nil, fmt.Errorf("invalid model name: %s", model)
+  }
+
   path := fmt.Sprintf(".%s.config", model)
   data, err := os.ReadFile(path)
   if err != nil {
@@ -201,93 +223,322 @@ func loadConfig(model string) (*ProviderConfig, error) {
 }
 
 //**********************************************************************
-// Generic API call
-func callAPI(ctx context.Context, cfg *ProviderConfig, prompt string, maxTokens int) (string, error) {
-  client := &http.Client{Timeout: 30 * time.Second}
-  
-  var reqBody map[string]interface{}
-  
+// StreamFunc receives each decoded token as it arrives from a streaming
+// completion, so library consumers can subscribe without waiting for EOF.
+type StreamFunc func(chunk string)
+
+//**********************************************************************
+// Provider is the pluggable dispatch surface every backend implements.
+// httpProvider wraps the REST/SSE path below; grpcProvider (grpcProvider.go)
+// talks to locally hosted model runners instead.
+type Provider interface {
+  Call(ctx context.Context, prompt string, maxTokens int) (string, error)
+}
+
+//**********************************************************************
+// StreamingProvider is implemented by providers that can hand back tokens
+// as they arrive instead of only the final text.
+type StreamingProvider interface {
+  Provider
+  CallStream(ctx context.Context, prompt string, maxTokens int, onChunk StreamFunc) (string, error)
+}
+
+//**********************************************************************
+// httpProvider is the original HTTP/SSE call path, wrapped behind Provider
+// so callers don't need to know which transport a model is served over.
+type httpProvider struct {
+  cfg            *ProviderConfig
+  connectTimeout time.Duration
+}
+
+func (p *httpProvider) Call(ctx context.Context, prompt string, maxTokens int) (string, error) {
+  return callAPI(ctx, p.cfg, prompt, maxTokens, p.connectTimeout)
+}
+
+func (p *httpProvider) CallStream(ctx context.Context, prompt string, maxTokens int, onChunk StreamFunc) (string, error) {
+  return callAPIStream(ctx, p.cfg, prompt, maxTokens, p.connectTimeout, onChunk)
+}
+
+//**********************************************************************
+// newProvider picks the backend implementation for cfg.Type. connectTimeout
+// only applies to httpProvider - see deadline.go for why it's split out
+// from the overall context deadline.
+func newProvider(cfg *ProviderConfig, connectTimeout time.Duration) (Provider, error) {
+  switch cfg.Type {
+  case "grpc":
+    return newGRPCProvider(cfg)
+  case "anthropic", "openai", "ollama", "":
+    return &httpProvider{cfg: cfg, connectTimeout: connectTimeout}, nil
+  default:
+    // Anything else is assumed to be a plugin type, loaded from
+    // .sigo/plugins/<type>.so (see plugins.go).
+    impl, err := loadPlugin(cfg.Type)
+    if err != nil {
+      logError("Plugin: %v", err)
+      return nil, err
+    }
+    return &pluginProviderAdapter{cfg: cfg, impl: impl}, nil
+  }
+}
+
+//**********************************************************************
+// buildRequestBody assembles the provider-specific JSON body shared by
+// the plain and streaming call paths.
+func buildRequestBody(cfg *ProviderConfig, prompt string, maxTokens int, stream bool) (map[string]interface{}, error) {
   switch cfg.Type {
   case "anthropic":
-    reqBody = map[string]interface{}{
+    return map[string]interface{}{
       "model": cfg.Model,
       "messages": []map[string]string{
         {"role": "user", "content": prompt},
       },
       "max_tokens": maxTokens,
-    }
-    
+      "stream":     stream,
+    }, nil
+
   case "openai", "":
-    reqBody = map[string]interface{}{
+    return map[string]interface{}{
       "model": cfg.Model,
       "messages": []map[string]string{
         {"role": "user", "content": prompt},
       },
       "max_tokens": maxTokens,
-    }
-    
+      "stream":     stream,
+    }, nil
+
+  case "ollama":
+    return map[string]interface{}{
+      "model":  cfg.Model,
+      "prompt": prompt,
+      "stream": stream,
+    }, nil
+
   default:
-    return "", fmt.Errorf("unknown provider type: %s", cfg.Type)
+    return nil, fmt.Errorf("unknown provider type: %s", cfg.Type)
   }
-  
-  jsonData, _ := json.Marshal(reqBody)
-  req, err := http.NewRequestWithContext(ctx, "POST", cfg.Endpoint, bytes.NewBuffer(jsonData))
-  if err != nil {
-    return "", err
-  }
-  
-  // Headers
-  req.Header.Set("Content-Type", "application/json")
-  
+}
+
+//**********************************************************************
+// setAuthHeaders applies the per-provider auth scheme plus any extra
+// headers from the config.
+func setAuthHeaders(req *http.Request, cfg *ProviderConfig) {
   if cfg.Type == "anthropic" {
     req.Header.Set("x-api-key", cfg.APIKey)
     req.Header.Set("anthropic-version", "2023-06-01")
   } else {
     req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
   }
-  
+
   for k, v := range cfg.Headers {
     req.Header.Set(k, v)
   }
-  
+}
+
+//**********************************************************************
+// extractText pulls the completion text out of a provider's non-streaming
+// response body.
+func extractText(cfgType string, result map[string]interface{}) (string, error) {
+  if errMsg, ok := result["error"].(map[string]interface{}); ok {
+    return "", fmt.Errorf("%v", errMsg["message"])
+  }
+
+  switch cfgType {
+  case "anthropic":
+    if content, ok := result["content"].([]interface{}); ok && len(content) > 0 {
+      if text, ok := content[0].(map[string]interface{})["text"].(string); ok {
+        return text, nil
+      }
+    }
+
+  case "ollama":
+    if text, ok := result["response"].(string); ok {
+      return text, nil
+    }
+
+  default:
+    if choices, ok := result["choices"].([]interface{}); ok && len(choices) > 0 {
+      if msg, ok := choices[0].(map[string]interface{})["message"].(map[string]interface{}); ok {
+        if content, ok := msg["content"].(string); ok {
+          return content, nil
+        }
+      }
+    }
+  }
+
+  return "", fmt.Errorf("unexpected response format")
+}
+
+//**********************************************************************
+// Generic API call. ctx carries the overall wallclock deadline spanning
+// every retry; connectTimeout bounds only this one attempt's connect/
+// first-byte wait (see deadline.go). http.Client has no Timeout of its
+// own - a client-level timeout would apply to the whole response body
+// too and fight with the context deadline.
+func callAPI(ctx context.Context, cfg *ProviderConfig, prompt string, maxTokens int, connectTimeout time.Duration) (string, error) {
+  client := &http.Client{}
+
+  reqBody, err := buildRequestBody(cfg, prompt, maxTokens, false)
+  if err != nil {
+    return "", err
+  }
+
+  attemptCtx, arrived, cancel := newAttemptContext(ctx, connectTimeout)
+  defer cancel()
+
+  jsonData, _ := json.Marshal(reqBody)
+  req, err := http.NewRequestWithContext(attemptCtx, "POST", cfg.Endpoint, bytes.NewBuffer(jsonData))
+  if err != nil {
+    return "", err
+  }
+
+  // Headers
+  req.Header.Set("Content-Type", "application/json")
+  setAuthHeaders(req, cfg)
+
   // Execute
   resp, err := client.Do(req)
   if err != nil {
     return "", err
   }
+  arrived() // response headers are in; hand the rest of the read off to the overall deadline
   defer resp.Body.Close()
-  
+
   body, _ := io.ReadAll(resp.Body)
-  
+
   var result map[string]interface{}
   if err := json.Unmarshal(body, &result); err != nil {
     return "", fmt.Errorf("parse error: %v", err)
   }
-  
-  // Extract response
-  if cfg.Type == "anthropic" {
-    if errMsg, ok := result["error"].(map[string]interface{}); ok {
-      return "", fmt.Errorf("%v", errMsg["message"])
+
+  return extractText(cfg.Type, result)
+}
+
+//**********************************************************************
+// parseStreamLine decodes one line of a streaming response body and
+// returns the text chunk it carries, whether the stream is finished, and
+// any terminal error. Once a stream has produced its first chunk, callers
+// must treat a non-nil error here as final rather than retrying.
+func parseStreamLine(cfgType, line string) (string, bool, error) {
+  line = strings.TrimSpace(line)
+  if line == "" {
+    return "", false, nil
+  }
+
+  if cfgType == "ollama" {
+    var evt map[string]interface{}
+    if err := json.Unmarshal([]byte(line), &evt); err != nil {
+      return "", false, fmt.Errorf("stream parse error: %v", err)
     }
-    if content, ok := result["content"].([]interface{}); ok && len(content) > 0 {
-      if text, ok := content[0].(map[string]interface{})["text"].(string); ok {
-        return text, nil
+    chunk, _ := evt["response"].(string)
+    done, _ := evt["done"].(bool)
+    return chunk, done, nil
+  }
+
+  // Anthropic and OpenAI both frame chunks as SSE "data: {...}" lines.
+  data := strings.TrimPrefix(line, "data: ")
+  if data == line {
+    return "", false, nil // not a data: frame, e.g. "event: ..."
+  }
+  if data == "[DONE]" {
+    return "", true, nil
+  }
+
+  var evt map[string]interface{}
+  if err := json.Unmarshal([]byte(data), &evt); err != nil {
+    return "", false, fmt.Errorf("stream parse error: %v", err)
+  }
+  if errMsg, ok := evt["error"].(map[string]interface{}); ok {
+    return "", false, fmt.Errorf("%v", errMsg["message"])
+  }
+
+  if cfgType == "anthropic" {
+    if evt["type"] == "message_stop" {
+      return "", true, nil
+    }
+    if evt["type"] == "content_block_delta" {
+      if delta, ok := evt["delta"].(map[string]interface{}); ok {
+        if text, ok := delta["text"].(string); ok {
+          return text, false, nil
+        }
       }
     }
-  } else {
-    if errMsg, ok := result["error"].(map[string]interface{}); ok {
-      return "", fmt.Errorf("%v", errMsg["message"])
+    return "", false, nil
+  }
+
+  // openai
+  if choices, ok := evt["choices"].([]interface{}); ok && len(choices) > 0 {
+    if delta, ok := choices[0].(map[string]interface{})["delta"].(map[string]interface{}); ok {
+      if content, ok := delta["content"].(string); ok {
+        return content, false, nil
+      }
     }
-    if choices, ok := result["choices"].([]interface{}); ok && len(choices) > 0 {
-      if msg, ok := choices[0].(map[string]interface{})["message"].(map[string]interface{}); ok {
-        if content, ok := msg["content"].(string); ok {
-          return content, nil
-        }
+  }
+  return "", false, nil
+}
+
+//**********************************************************************
+// callAPIStream mirrors callAPI but consumes the response as it arrives,
+// invoking onChunk for every decoded token and accumulating the full text
+// for session persistence. A non-nil error returned after onChunk has
+// already fired at least once must be treated as terminal by the caller:
+// the retry loop only gets to retry connection/first-byte failures.
+func callAPIStream(ctx context.Context, cfg *ProviderConfig, prompt string, maxTokens int, connectTimeout time.Duration, onChunk StreamFunc) (string, error) {
+  client := &http.Client{}
+
+  reqBody, err := buildRequestBody(cfg, prompt, maxTokens, true)
+  if err != nil {
+    return "", err
+  }
+
+  attemptCtx, arrived, cancel := newAttemptContext(ctx, connectTimeout)
+  defer cancel()
+
+  jsonData, _ := json.Marshal(reqBody)
+  req, err := http.NewRequestWithContext(attemptCtx, "POST", cfg.Endpoint, bytes.NewBuffer(jsonData))
+  if err != nil {
+    return "", err
+  }
+
+  req.Header.Set("Content-Type", "application/json")
+  setAuthHeaders(req, cfg)
+
+  resp, err := client.Do(req)
+  if err != nil {
+    return "", err
+  }
+  arrived() // response headers are in; the overall deadline governs the rest of the stream
+  defer resp.Body.Close()
+
+  if resp.StatusCode != http.StatusOK {
+    body, _ := io.ReadAll(resp.Body)
+    return "", fmt.Errorf("http %d: %s", resp.StatusCode, body)
+  }
+
+  var full strings.Builder
+  scanner := bufio.NewScanner(resp.Body)
+  scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+  for scanner.Scan() {
+    chunk, done, perr := parseStreamLine(cfg.Type, scanner.Text())
+    if perr != nil {
+      return full.String(), perr
+    }
+    if chunk != "" {
+      full.WriteString(chunk)
+      if onChunk != nil {
+        onChunk(chunk)
       }
     }
+    if done {
+      break
+    }
   }
-  
-  return "", fmt.Errorf("unexpected response format")
+
+  if err := scanner.Err(); err != nil {
+    return full.String(), err
+  }
+
+  return full.String(), nil
 }
 
 //**********************************************************************
@@ -363,80 +614,157 @@ func getInput() (string, error) {
 
 //**********************************************************************
 func main() {
+  // "sigo token ..." is a standalone subcommand with its own flags, so it
+  // has to be special-cased before the main FlagSet sees os.Args.
+  if len(os.Args) > 1 && os.Args[1] == "token" {
+    runTokenCommand(os.Args[2:])
+    return
+  }
+  if len(os.Args) > 1 && os.Args[1] == "plugin" {
+    runPluginCommand(os.Args[2:])
+    return
+  }
+
   // Flags
   var (
-    model     = flag.String("m",     "claude4", "Model to use")
-    sessionID = flag.String("s", "", "Session ID")
-    maxTokens = flag.Int("n", 1024,  "Max tokens")
-    timeout   = flag.Int("t", 30,    "Timeout seconds")
-    retries   = flag.Int("r", 3,     "Retry count")
-    quiet     = flag.Bool("q", false, "Quiet mode")
-    jsonOut   = flag.Bool("j", false, "JSON output")
-    help      = flag.Bool("h", false, "Show help")
+    model          = flag.String("m", "claude4", "Model to use")
+    sessionID      = flag.String("s", "", "Session ID")
+    maxTokens      = flag.Int("n", 1024, "Max tokens")
+    timeout        = flag.Int("t", 30, "Timeout seconds (overall deadline, kept for backwards compatibility - see -deadline)")
+    connectTimeout = flag.Duration("connect-timeout", defaultConnectTimeout, "Per-attempt deadline for connecting and receiving the first response byte")
+    deadlineFlag   = flag.Duration("deadline", 0, "Overall wallclock deadline spanning all retries (default: -t seconds)")
+    retries        = flag.Int("r", 3, "Retry count")
+    quiet          = flag.Bool("q", false, "Quiet mode")
+    jsonOut        = flag.Bool("j", false, "JSON output")
+    stream         = flag.Bool("stream", false, "Stream the completion token by token")
+    fanout         = flag.String("fanout", "", "Comma-separated models to dispatch the prompt to concurrently")
+    strategy       = flag.String("strategy", "first", "Fanout strategy: first, all, vote, judge:<model>")
+    serve          = flag.String("serve", "", "Run as a daemon, serving the HTTP API on this address (e.g. :8080)")
+    help           = flag.Bool("h", false, "Show help")
   )
-  
+
   flag.Parse()
-  
+
   // Help
-  if *help || (flag.NArg() == 0 && flag.NFlag() == 0) {
+  if *help || (flag.NArg() == 0 && flag.NFlag() == 0 && *serve == "") {
     showHelp()
     os.Exit(0)
   }
-  
-  // Load config
-  cfg, err := loadConfig(*model)
-  if err != nil {
-    logError("Config: %v", err)
-    os.Exit(1)
+
+  if *serve != "" {
+    secret := []byte(os.Getenv("SIGO_JWT_SECRET"))
+    if len(secret) == 0 {
+      logError("Serve: SIGO_JWT_SECRET not set")
+      os.Exit(1)
+    }
+    if err := runServe(*serve, secret); err != nil {
+      logError("Serve: %v", err)
+      os.Exit(1)
+    }
+    return
   }
-  
+
   // Get input
   prompt, err := getInput()
   if err != nil || prompt == "" {
     logError("No input")
     os.Exit(1)
   }
-  
+
   // Session
   session := loadSession(*sessionID, *model)
   contextPrompt := session.buildPrompt(prompt)
-  
+
+  if *fanout != "" {
+    ok := runFanoutHandler(strings.Split(*fanout, ","), *strategy,
+      *sessionID, *model, *maxTokens, *timeout, *jsonOut, prompt, contextPrompt, session)
+    if !ok {
+      os.Exit(1)
+    }
+    os.Exit(0)
+  }
+
+  // Load config
+  cfg, err := loadConfig(*model)
+  if err != nil {
+    logError("Config: %v", err)
+    os.Exit(1)
+  }
+
+  provider, err := newProvider(cfg, *connectTimeout)
+  if err != nil {
+    logError("Provider: %v", err)
+    os.Exit(1)
+  }
+  if closer, ok := provider.(interface{ Close() error }); ok {
+    defer closer.Close()
+  }
+
   // Circuit breaker
   breaker := NewCircuitBreaker()
-  
+
   // Execute with retries
   var resp Response
   resp.Model = *model
   resp.PID = os.Getpid()
   resp.Timestamp = time.Now().Unix()
   resp.Prompt = prompt
-  
+
   start := time.Now()
-  
-  ctx, cancel := context.WithTimeout(context.Background(), 
-    time.Duration(*timeout)*time.Second)
+
+  deadline := *deadlineFlag
+  if deadline == 0 {
+    deadline = time.Duration(*timeout) * time.Second
+  }
+
+  ctx, cancel := context.WithTimeout(context.Background(), deadline)
   defer cancel()
   
   var lastErr error
+  streamStarted := false
   for i := 0; i < *retries; i++ {
     err := breaker.Do(func() error {
-      result, err := callAPI(ctx, cfg, contextPrompt, *maxTokens)
+      if *stream {
+        sp, ok := provider.(StreamingProvider)
+        if !ok {
+          return fmt.Errorf("model %q does not support streaming", *model)
+        }
+        result, err := sp.CallStream(ctx, contextPrompt, *maxTokens, func(chunk string) {
+          streamStarted = true
+          if !*jsonOut {
+            fmt.Print(chunk)
+          }
+        })
+        resp.Response = result
+        return err
+      }
+      result, err := provider.Call(ctx, contextPrompt, *maxTokens)
       if err != nil {
         return err
       }
       resp.Response = result
       return nil
     })
-    
+
     if err == nil {
       break
     }
     lastErr = err
-    
+
+    // Once the stream has delivered its first chunk, a later error is
+    // terminal: retrying would replay tokens already written to stdout.
+    if streamStarted {
+      break
+    }
+
     if i < *retries-1 {
       time.Sleep(time.Duration(i+1) * time.Second)
     }
   }
+
+  if *stream && !*jsonOut && lastErr == nil {
+    fmt.Println()
+  }
   
   if lastErr != nil {
     resp.Error = lastErr.Error()
@@ -461,7 +789,9 @@ func main() {
       }
       os.Exit(1)
     }
-    fmt.Println(resp.Response)
+    if !*stream {
+      fmt.Println(resp.Response)
+    }
   }
   
   if resp.Error != "" {